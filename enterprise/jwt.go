@@ -0,0 +1,158 @@
+package enterprise
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type contextKey int
+
+// userContextKey is the context key under which JWTAuth stores the
+// authenticated User.
+const userContextKey contextKey = iota
+
+// newContextWithUser returns a copy of ctx carrying user.
+func newContextWithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the User attached to ctx by JWTAuth, if any.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userContextKey).(*User)
+	return user, ok
+}
+
+// SigningMethod selects the algorithm used to sign and verify service
+// tokens. Only symmetric (HS256) and asymmetric (RS256) signing are
+// supported; anything else is a configuration error.
+type SigningMethod string
+
+const (
+	// SigningMethodHS256 signs and verifies tokens with a single shared secret.
+	SigningMethodHS256 SigningMethod = "HS256"
+	// SigningMethodRS256 signs with an RSA private key and verifies with
+	// its public counterpart, so verification doesn't require the signing
+	// secret.
+	SigningMethodRS256 SigningMethod = "RS256"
+)
+
+// JWTConfig holds the key material used to mint and verify enterprise
+// service tokens.
+type JWTConfig struct {
+	Method     SigningMethod
+	Secret     []byte          // used when Method is SigningMethodHS256
+	PrivateKey *rsa.PrivateKey // used when Method is SigningMethodRS256 to sign
+	PublicKey  *rsa.PublicKey  // used when Method is SigningMethodRS256 to verify
+}
+
+// ServiceClaims are the JWT claims carried by an enterprise service token.
+// Sub identifies the enterprise user the token acts as; Roles are mapped
+// onto that user's Permissions when the token is verified.
+type ServiceClaims struct {
+	jwt.StandardClaims
+	Roles []string `json:"roles"`
+}
+
+// NewServiceToken mints a short-lived JWT for user that is valid for ttl,
+// signed according to cfg.
+func NewServiceToken(cfg JWTConfig, user string, roles []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := ServiceClaims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		Roles: roles,
+	}
+
+	switch cfg.Method {
+	case SigningMethodRS256:
+		if cfg.PrivateKey == nil {
+			return "", fmt.Errorf("RS256 signing requires a private key")
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		return token.SignedString(cfg.PrivateKey)
+	case SigningMethodHS256:
+		if len(cfg.Secret) == 0 {
+			return "", fmt.Errorf("HS256 signing requires a secret")
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(cfg.Secret)
+	default:
+		return "", fmt.Errorf("unknown JWT signing method %q", cfg.Method)
+	}
+}
+
+// verifyServiceToken parses and validates raw against cfg, returning the
+// claims it carries.
+func verifyServiceToken(cfg JWTConfig, raw string) (*ServiceClaims, error) {
+	claims := &ServiceClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		switch cfg.Method {
+		case SigningMethodRS256:
+			if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return cfg.PublicKey, nil
+		case SigningMethodHS256:
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return cfg.Secret, nil
+		default:
+			return nil, fmt.Errorf("unknown JWT signing method %q", cfg.Method)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// rolesToPermissions flattens a set of enterprise role names into the
+// Permissions a bearer token grants. Each role name is used as a
+// Permissions key (a database, or a narrower path that ParseResource
+// accepts) granting read and write access.
+func rolesToPermissions(roles []string) Permissions {
+	perms := make(Permissions, len(roles))
+	for _, role := range roles {
+		perms[role] = []string{"ReadData", "WriteData"}
+	}
+	return perms
+}
+
+// JWTAuth returns middleware that authenticates requests bearing an
+// "Authorization: Bearer <jwt>" header signed according to cfg. On success
+// the request's User (reconstructed from the token's sub/roles claims) is
+// attached to the request context under userContextKey before next is
+// called; on failure it writes 401 and does not call next.
+func JWTAuth(cfg JWTConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		raw := strings.TrimPrefix(header, "Bearer ")
+
+		claims, err := verifyServiceToken(cfg, raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid token: %v", err), http.StatusUnauthorized)
+			return
+		}
+
+		user := &User{
+			Name:        claims.Subject,
+			Permissions: rolesToPermissions(claims.Roles),
+		}
+		ctx := newContextWithUser(r.Context(), user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
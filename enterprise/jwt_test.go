@@ -0,0 +1,63 @@
+package enterprise
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceTokenRoundTripHS256(t *testing.T) {
+	cfg := JWTConfig{Method: SigningMethodHS256, Secret: []byte("test-secret")}
+
+	token, err := NewServiceToken(cfg, "alice", []string{"mydb"}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewServiceToken: %v", err)
+	}
+
+	claims, err := verifyServiceToken(cfg, token)
+	if err != nil {
+		t.Fatalf("verifyServiceToken: %v", err)
+	}
+	if claims.Subject != "alice" {
+		t.Errorf("claims.Subject = %q, want %q", claims.Subject, "alice")
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "mydb" {
+		t.Errorf("claims.Roles = %v, want [mydb]", claims.Roles)
+	}
+}
+
+func TestServiceTokenExpired(t *testing.T) {
+	cfg := JWTConfig{Method: SigningMethodHS256, Secret: []byte("test-secret")}
+
+	token, err := NewServiceToken(cfg, "alice", nil, -time.Minute)
+	if err != nil {
+		t.Fatalf("NewServiceToken: %v", err)
+	}
+
+	if _, err := verifyServiceToken(cfg, token); err == nil {
+		t.Errorf("expected verifyServiceToken to reject an expired token, got nil error")
+	}
+}
+
+func TestServiceTokenWrongSecretRejected(t *testing.T) {
+	signing := JWTConfig{Method: SigningMethodHS256, Secret: []byte("right-secret")}
+	verifying := JWTConfig{Method: SigningMethodHS256, Secret: []byte("wrong-secret")}
+
+	token, err := NewServiceToken(signing, "alice", nil, time.Hour)
+	if err != nil {
+		t.Fatalf("NewServiceToken: %v", err)
+	}
+
+	if _, err := verifyServiceToken(verifying, token); err == nil {
+		t.Errorf("expected verifyServiceToken to reject a token signed with a different secret")
+	}
+}
+
+func TestRolesToPermissions(t *testing.T) {
+	perms := rolesToPermissions([]string{"mydb", "otherdb"})
+	if !perms.Allows("ReadData", Resource{Database: "mydb"}) {
+		t.Errorf("expected role %q to grant ReadData", "mydb")
+	}
+	if !perms.Allows("WriteData", Resource{Database: "otherdb"}) {
+		t.Errorf("expected role %q to grant WriteData", "otherdb")
+	}
+}
@@ -0,0 +1,301 @@
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailoverPolicy determines how the query layer chooses among healthy
+// DataNodes when more than one is available.
+type FailoverPolicy string
+
+const (
+	// FailoverRoundRobin cycles through healthy DataNodes in turn.
+	FailoverRoundRobin FailoverPolicy = "round-robin"
+	// FailoverSticky always prefers the last DataNode that was healthy,
+	// only moving on when it stops reporting "ok".
+	FailoverSticky FailoverPolicy = "sticky"
+	// FailoverLowestLatency prefers whichever healthy DataNode most
+	// recently reported the lowest poll latency.
+	FailoverLowestLatency FailoverPolicy = "lowest-latency"
+)
+
+// nodeStatusOK is the Status value a node reports when it is able to
+// serve reads and writes.
+const nodeStatusOK = "ok"
+
+// NodeHealth is the most recently observed health of a single node.
+type NodeHealth struct {
+	Status           string        `json:"status"`
+	LastCheck        time.Time     `json:"lastCheck"`
+	Latency          time.Duration `json:"latency"`
+	ConsecutiveFails int           `json:"consecutiveFails"`
+}
+
+// ClusterManager periodically polls every meta and data node in a Cluster,
+// caches their health, and provides the operations an administrator needs
+// to safely take a meta node out of service.
+type ClusterManager struct {
+	client   *http.Client
+	interval time.Duration
+
+	mu       sync.RWMutex
+	cluster  Cluster
+	health   map[string]NodeHealth
+	sticky   string // address of the last known-healthy DataNode, for FailoverSticky
+	rrCursor int    // next index into the healthy set for FailoverRoundRobin
+}
+
+// NewClusterManager returns a ClusterManager that polls the given cluster's
+// nodes at interval using client. A nil client uses http.DefaultClient.
+func NewClusterManager(cluster Cluster, interval time.Duration, client *http.Client) *ClusterManager {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ClusterManager{
+		client:   client,
+		interval: interval,
+		cluster:  cluster,
+		health:   make(map[string]NodeHealth),
+	}
+}
+
+// Run polls every node's status on interval until ctx is canceled.
+func (m *ClusterManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.pollAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.pollAll(ctx)
+		}
+	}
+}
+
+// nodeEndpoint is the address and scheme needed to reach a node's HTTP
+// admin API, whether it's a DataNode or a meta Node.
+type nodeEndpoint struct {
+	addr   string
+	scheme string
+}
+
+func (e nodeEndpoint) url(path string) string {
+	scheme := e.scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, e.addr, path)
+}
+
+func (m *ClusterManager) pollAll(ctx context.Context) {
+	m.mu.RLock()
+	endpoints := make([]nodeEndpoint, 0, len(m.cluster.DataNodes)+len(m.cluster.MetaNodes))
+	for _, n := range m.cluster.DataNodes {
+		endpoints = append(endpoints, nodeEndpoint{addr: n.HTTPAddr, scheme: n.HTTPScheme})
+	}
+	for _, n := range m.cluster.MetaNodes {
+		endpoints = append(endpoints, nodeEndpoint{addr: n.Addr, scheme: n.HTTPScheme})
+	}
+	m.mu.RUnlock()
+
+	for _, ep := range endpoints {
+		m.poll(ctx, ep)
+	}
+}
+
+// poll fetches a single node's status from its "/status" endpoint and
+// records the result in the health cache.
+func (m *ClusterManager) poll(ctx context.Context, ep nodeEndpoint) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", ep.url("/status"), nil)
+	if err != nil {
+		m.recordFailure(ep.addr)
+		return
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		m.recordFailure(ep.addr)
+		return
+	}
+	resp.Body.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health[ep.addr] = NodeHealth{
+		Status:    nodeStatusOK,
+		LastCheck: start,
+		Latency:   time.Since(start),
+	}
+}
+
+func (m *ClusterManager) recordFailure(addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.health[addr]
+	m.health[addr] = NodeHealth{
+		Status:           "unreachable",
+		LastCheck:        time.Now(),
+		ConsecutiveFails: prev.ConsecutiveFails + 1,
+	}
+}
+
+// Cluster returns the cluster topology this manager is polling.
+func (m *ClusterManager) Cluster() Cluster {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cluster
+}
+
+// Health returns the last observed health for addr and whether anything
+// has been recorded for it yet.
+func (m *ClusterManager) Health(addr string) (NodeHealth, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.health[addr]
+	return h, ok
+}
+
+// HealthyDataNode selects a DataNode to route a query to according to
+// policy, consulting the health cache so that nodes whose Status isn't
+// "ok" are skipped. It returns an error if no DataNode is currently healthy.
+func (m *ClusterManager) HealthyDataNode(policy FailoverPolicy) (*DataNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var healthy []DataNode
+	for _, n := range m.cluster.DataNodes {
+		if h, ok := m.health[n.HTTPAddr]; ok && h.Status == nodeStatusOK {
+			healthy = append(healthy, n)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no healthy data nodes available")
+	}
+
+	switch policy {
+	case FailoverSticky:
+		for _, n := range healthy {
+			if n.HTTPAddr == m.sticky {
+				return &n, nil
+			}
+		}
+		m.sticky = healthy[0].HTTPAddr
+		return &healthy[0], nil
+	case FailoverLowestLatency:
+		best := healthy[0]
+		bestLatency := m.health[best.HTTPAddr].Latency
+		for _, n := range healthy[1:] {
+			if l := m.health[n.HTTPAddr].Latency; l < bestLatency {
+				best, bestLatency = n, l
+			}
+		}
+		m.sticky = best.HTTPAddr
+		return &best, nil
+	default: // FailoverRoundRobin
+		n := healthy[m.rrCursor%len(healthy)]
+		m.rrCursor++
+		m.sticky = n.HTTPAddr
+		return &n, nil
+	}
+}
+
+// StepDownMetaNode drains writes away from the meta leader at addr and
+// removes it from service. It requests a leadership transfer up to
+// maxAttempts times, backing off between attempts, so that in-flight
+// writes are not interrupted by an abrupt leader loss; only once a
+// transfer succeeds does it remove the node from the cluster.
+func (m *ClusterManager) StepDownMetaNode(ctx context.Context, addr string, maxAttempts int, backoff time.Duration) error {
+	ep := m.metaNodeEndpoint(addr)
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff * time.Duration(attempt)):
+			}
+		}
+
+		if err := m.transferLeadership(ctx, ep); err != nil {
+			lastErr = err
+			continue
+		}
+		return m.removeMetaNode(ctx, ep)
+	}
+	return fmt.Errorf("step down %s: giving up after %d attempts: %v", addr, maxAttempts, lastErr)
+}
+
+// metaNodeEndpoint looks up the scheme the cluster reported for the meta
+// node at addr, defaulting to https if the node isn't known.
+func (m *ClusterManager) metaNodeEndpoint(addr string) nodeEndpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, n := range m.cluster.MetaNodes {
+		if n.Addr == addr {
+			return nodeEndpoint{addr: n.Addr, scheme: n.HTTPScheme}
+		}
+	}
+	return nodeEndpoint{addr: addr}
+}
+
+func (m *ClusterManager) transferLeadership(ctx context.Context, ep nodeEndpoint) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", ep.url("/leadership/transfer"), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("leadership transfer request to %s returned %s", ep.addr, resp.Status)
+	}
+	return nil
+}
+
+// removeMetaNode asks the cluster to remove the (by now leaderless) meta
+// node at ep from service, and drops it from the locally cached topology
+// so polling and the admin status view stop considering it.
+func (m *ClusterManager) removeMetaNode(ctx context.Context, ep nodeEndpoint) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", ep.url("/remove"), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("removing meta node %s: %v", ep.addr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("remove request to %s returned %s", ep.addr, resp.Status)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	remaining := m.cluster.MetaNodes[:0]
+	for _, n := range m.cluster.MetaNodes {
+		if n.Addr != ep.addr {
+			remaining = append(remaining, n)
+		}
+	}
+	m.cluster.MetaNodes = remaining
+	delete(m.health, ep.addr)
+	return nil
+}
@@ -0,0 +1,177 @@
+package enterprise
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagPredicate restricts a Resource to tag values matching Pattern for Key.
+// Pattern supports a single trailing "*" wildcard, e.g. "web*".
+type TagPredicate struct {
+	Key     string
+	Pattern string
+}
+
+// Matches reports whether value satisfies p.
+func (p TagPredicate) Matches(value string) bool {
+	if strings.HasSuffix(p.Pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(p.Pattern, "*"))
+	}
+	return value == p.Pattern
+}
+
+// Resource is a parsed Permissions key: a database, optionally scoped down
+// to a retention policy and measurement, optionally further restricted by
+// tag value predicates. The legacy, database-only form ("mydb") parses to
+// a Resource with only Database set.
+type Resource struct {
+	Database        string
+	RetentionPolicy string
+	Measurement     string
+	Tags            []TagPredicate
+}
+
+// String renders r back into the Permissions key syntax it was parsed
+// from, e.g. "mydb.autogen.cpu[host=web*]".
+func (r Resource) String() string {
+	var b strings.Builder
+	b.WriteString(r.Database)
+	if r.RetentionPolicy != "" {
+		fmt.Fprintf(&b, ".%s", r.RetentionPolicy)
+	}
+	if r.Measurement != "" {
+		fmt.Fprintf(&b, ".%s", r.Measurement)
+	}
+	if len(r.Tags) > 0 {
+		b.WriteString("[")
+		for i, t := range r.Tags {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "%s=%s", t.Key, t.Pattern)
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
+
+// ParseResource parses a Permissions key into a Resource. Keys are
+// "db", "db.rp", or "db.rp.measurement", each optionally suffixed with
+// "[tag=pattern,...]". A bare database name (the legacy form) parses
+// successfully to a Resource with only Database set.
+func ParseResource(key string) (Resource, error) {
+	path := key
+	var tagSpec string
+	if idx := strings.IndexByte(key, '['); idx >= 0 {
+		if !strings.HasSuffix(key, "]") {
+			return Resource{}, fmt.Errorf("permission key %q has an unterminated tag predicate", key)
+		}
+		path = key[:idx]
+		tagSpec = key[idx+1 : len(key)-1]
+	}
+
+	parts := strings.Split(path, ".")
+	if len(parts) > 3 {
+		return Resource{}, fmt.Errorf("permission key %q has too many path segments", key)
+	}
+
+	r := Resource{Database: parts[0]}
+	if len(parts) > 1 {
+		r.RetentionPolicy = parts[1]
+	}
+	if len(parts) > 2 {
+		r.Measurement = parts[2]
+	}
+
+	if tagSpec != "" {
+		for _, pred := range strings.Split(tagSpec, ",") {
+			kv := strings.SplitN(pred, "=", 2)
+			if len(kv) != 2 {
+				return Resource{}, fmt.Errorf("permission key %q has a malformed tag predicate %q", key, pred)
+			}
+			r.Tags = append(r.Tags, TagPredicate{Key: kv[0], Pattern: kv[1]})
+		}
+	}
+	return r, nil
+}
+
+// Scopes parses every key of p into a Resource, skipping (rather than
+// failing on) any key that doesn't parse so that a single malformed entry
+// doesn't take down authorization for the rest of the user's grants.
+func (p Permissions) Scopes() map[string]Resource {
+	scopes := make(map[string]Resource, len(p))
+	for key := range p {
+		if r, err := ParseResource(key); err == nil {
+			scopes[key] = r
+		}
+	}
+	return scopes
+}
+
+// Allows reports whether p grants action on the database/retention
+// policy/measurement/tags addressed by want. A grant on a broader resource
+// (e.g. just the database) authorizes narrower resources beneath it,
+// provided any tag predicates on the grant also match want's tags.
+func (p Permissions) Allows(action string, want Resource) bool {
+	scopes := p.Scopes()
+	for key, grant := range scopes {
+		if !grant.covers(want) {
+			continue
+		}
+		for _, a := range p[key] {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// covers reports whether a grant on r authorizes access to want: r's path
+// must be a prefix of (or equal to) want's, and every tag predicate on r
+// must match the corresponding tag value in want.Tags.
+func (r Resource) covers(want Resource) bool {
+	if !r.coversPath(want) {
+		return false
+	}
+
+	for _, pred := range r.Tags {
+		matched := false
+		for _, wantTag := range want.Tags {
+			if wantTag.Key == pred.Key && pred.Matches(wantTag.Pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// coversPath reports whether r's database/retention-policy/measurement
+// path is a prefix of (or equal to) want's, ignoring any tag predicates on
+// r. This is what RewriteQuery uses to find the grant whose tag
+// predicates (if any) should be injected as a WHERE filter on an
+// unscoped query, rather than requiring the query to already name the
+// tags the grant restricts it to.
+//
+// An empty RetentionPolicy or Measurement on want is treated as a
+// wildcard rather than an exact-match requirement: a query that doesn't
+// narrow to an RP or measurement (InfluxQL callers almost never spell
+// the RP out, relying on the database's default) is still covered by a
+// grant scoped to one, since the grant is the authority on what's
+// accessible, not the query's level of detail.
+func (r Resource) coversPath(want Resource) bool {
+	if r.Database != want.Database {
+		return false
+	}
+	if r.RetentionPolicy != "" && want.RetentionPolicy != "" && r.RetentionPolicy != want.RetentionPolicy {
+		return false
+	}
+	if r.Measurement != "" && want.Measurement != "" && r.Measurement != want.Measurement {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package enterprise
+
+import "testing"
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    Resource
+		wantErr bool
+	}{
+		{key: "mydb", want: Resource{Database: "mydb"}},
+		{key: "mydb.autogen", want: Resource{Database: "mydb", RetentionPolicy: "autogen"}},
+		{
+			key:  "mydb.autogen.cpu",
+			want: Resource{Database: "mydb", RetentionPolicy: "autogen", Measurement: "cpu"},
+		},
+		{
+			key: "mydb.autogen.cpu[host=web*]",
+			want: Resource{
+				Database: "mydb", RetentionPolicy: "autogen", Measurement: "cpu",
+				Tags: []TagPredicate{{Key: "host", Pattern: "web*"}},
+			},
+		},
+		{key: "mydb.autogen.cpu.extra", wantErr: true},
+		{key: "mydb[host=web", wantErr: true},
+		{key: "mydb[badpredicate]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResource(tt.key)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseResource(%q) expected an error, got %+v", tt.key, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseResource(%q) returned unexpected error: %v", tt.key, err)
+			continue
+		}
+		if got.Database != tt.want.Database || got.RetentionPolicy != tt.want.RetentionPolicy ||
+			got.Measurement != tt.want.Measurement || len(got.Tags) != len(tt.want.Tags) {
+			t.Errorf("ParseResource(%q) = %+v, want %+v", tt.key, got, tt.want)
+			continue
+		}
+		for i, tag := range got.Tags {
+			if tag != tt.want.Tags[i] {
+				t.Errorf("ParseResource(%q).Tags[%d] = %+v, want %+v", tt.key, i, tag, tt.want.Tags[i])
+			}
+		}
+	}
+}
+
+func TestTagPredicateMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "web01", value: "web01", want: true},
+		{pattern: "web01", value: "web02", want: false},
+		{pattern: "web*", value: "web01", want: true},
+		{pattern: "web*", value: "db01", want: false},
+	}
+
+	for _, tt := range tests {
+		pred := TagPredicate{Key: "host", Pattern: tt.pattern}
+		if got := pred.Matches(tt.value); got != tt.want {
+			t.Errorf("TagPredicate{Pattern: %q}.Matches(%q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestResourceCovers(t *testing.T) {
+	tests := []struct {
+		name       string
+		r          Resource
+		resource   Resource
+		wantCovers bool
+	}{
+		{
+			name:       "db grant covers measurement in db",
+			r:          Resource{Database: "mydb"},
+			resource:   Resource{Database: "mydb", Measurement: "cpu"},
+			wantCovers: true,
+		},
+		{
+			name:       "db grant does not cover a different db",
+			r:          Resource{Database: "mydb"},
+			resource:   Resource{Database: "otherdb", Measurement: "cpu"},
+			wantCovers: false,
+		},
+		{
+			name:       "measurement grant does not cover a different measurement",
+			r:          Resource{Database: "mydb", Measurement: "cpu"},
+			resource:   Resource{Database: "mydb", Measurement: "mem"},
+			wantCovers: false,
+		},
+		{
+			name:       "tag-scoped grant covers only matching tag values",
+			r:          Resource{Database: "mydb", Measurement: "cpu", Tags: []TagPredicate{{Key: "host", Pattern: "web*"}}},
+			resource:   Resource{Database: "mydb", Measurement: "cpu", Tags: []TagPredicate{{Key: "host", Pattern: "web01"}}},
+			wantCovers: true,
+		},
+		{
+			name:       "tag-scoped grant rejects a non-matching tag value",
+			r:          Resource{Database: "mydb", Measurement: "cpu", Tags: []TagPredicate{{Key: "host", Pattern: "web*"}}},
+			resource:   Resource{Database: "mydb", Measurement: "cpu", Tags: []TagPredicate{{Key: "host", Pattern: "db01"}}},
+			wantCovers: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.covers(tt.resource); got != tt.wantCovers {
+				t.Errorf("covers() = %v, want %v", got, tt.wantCovers)
+			}
+		})
+	}
+}
+
+func TestResourceCoversPathIgnoresTags(t *testing.T) {
+	// coversPath is what RewriteQuery uses to find the grant whose tag
+	// predicates should be injected as a filter: it must match an
+	// unscoped want (no tags yet), unlike covers.
+	r := Resource{Database: "mydb", Measurement: "cpu", Tags: []TagPredicate{{Key: "host", Pattern: "web*"}}}
+	want := Resource{Database: "mydb", Measurement: "cpu"}
+
+	if !r.coversPath(want) {
+		t.Errorf("coversPath() = false, want true for an unscoped query under a tag-scoped grant")
+	}
+	if r.covers(want) {
+		t.Errorf("covers() = true, want false: an unscoped want has no tag value to satisfy the grant's predicate")
+	}
+}
+
+func TestPermissionsAllows(t *testing.T) {
+	perms := Permissions{
+		"mydb":                    {"ReadData"},
+		"otherdb.autogen.secrets": {"WriteData"},
+	}
+
+	if !perms.Allows("ReadData", Resource{Database: "mydb", Measurement: "cpu"}) {
+		t.Errorf("expected db-level grant to authorize ReadData on mydb.cpu")
+	}
+	if perms.Allows("WriteData", Resource{Database: "mydb", Measurement: "cpu"}) {
+		t.Errorf("did not expect ReadData-only grant to authorize WriteData")
+	}
+	if perms.Allows("ReadData", Resource{Database: "otherdb", RetentionPolicy: "autogen", Measurement: "secrets"}) {
+		t.Errorf("did not expect a WriteData-only grant to authorize ReadData")
+	}
+}
@@ -0,0 +1,129 @@
+package enterprise
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// RewriteQuery parses an InfluxQL query executed against db, checks that
+// perms grants the given action on every measurement it reads from, and
+// returns a rewritten query with a WHERE clause injected for any
+// measurement scoped down by tag predicates. It returns an error if perms
+// doesn't grant action on some measurement the query touches. A source
+// that names its own database or retention policy (e.g. "FROM
+// otherdb.autogen.cpu") is checked against those rather than db.
+//
+// Only SELECT statements reading plain "FROM measurement" sources can be
+// scope-checked. Flux isn't InfluxQL and can't be inspected by this
+// rewriter at all; a subquery source (e.g. "FROM (SELECT ... FROM
+// secret)") could read a measurement this function never sees if it let
+// the outer statement through; a regex source (e.g. "FROM /.*/") names
+// no single measurement to check; and statements like DELETE or DROP
+// MEASUREMENT name a measurement this function doesn't walk the same way
+// a SELECT's Sources do. All of these are explicitly rejected rather than
+// silently allowed through unchecked.
+func RewriteQuery(query, db, action string, perms Permissions) (string, error) {
+	if looksLikeFlux(query) {
+		return "", fmt.Errorf("permission denied: Flux queries cannot yet be scope-checked by the enterprise permission rewriter")
+	}
+
+	parsed, err := influxql.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("parsing query: %v", err)
+	}
+
+	for _, stmt := range parsed.Statements {
+		sel, ok := stmt.(*influxql.SelectStatement)
+		if !ok {
+			return "", fmt.Errorf("permission denied: statement %T cannot be scope-checked by the enterprise permission rewriter", stmt)
+		}
+
+		for _, src := range sel.Sources {
+			measurement, ok := src.(*influxql.Measurement)
+			if !ok {
+				return "", fmt.Errorf("permission denied: query source %T cannot be scope-checked by the enterprise permission rewriter", src)
+			}
+
+			if measurement.Regex != nil {
+				return "", fmt.Errorf("permission denied: regex source %s cannot be scope-checked by the enterprise permission rewriter", measurement)
+			}
+
+			database := db
+			if measurement.Database != "" {
+				database = measurement.Database
+			}
+			want := Resource{Database: database, RetentionPolicy: measurement.RetentionPolicy, Measurement: measurement.Name}
+			grant, ok := widestGrant(perms, action, want)
+			if !ok {
+				return "", fmt.Errorf("permission denied: %s is not authorized to %s %s.%s", db, action, database, measurement.Name)
+			}
+
+			if len(grant.Tags) > 0 {
+				sel.Condition = injectTagConditions(sel.Condition, grant.Tags)
+			}
+		}
+	}
+	return parsed.String(), nil
+}
+
+// looksLikeFlux reports whether query appears to be Flux rather than
+// InfluxQL, using the syntax markers ("from(" pipelines, "|>") that never
+// appear in valid InfluxQL.
+func looksLikeFlux(query string) bool {
+	trimmed := strings.TrimSpace(query)
+	return strings.Contains(trimmed, "|>") || strings.HasPrefix(trimmed, "from(")
+}
+
+// widestGrant returns the broadest Resource perms grants action on that
+// covers want, so the tag predicates (if any) attached to that grant can
+// be injected into the query's WHERE clause.
+func widestGrant(perms Permissions, action string, want Resource) (Resource, bool) {
+	var best Resource
+	found := false
+	for key, grant := range perms.Scopes() {
+		if !grant.coversPath(want) {
+			continue
+		}
+		for _, a := range perms[key] {
+			if a != action {
+				continue
+			}
+			if !found || len(grant.Tags) < len(best.Tags) {
+				best, found = grant, true
+			}
+		}
+	}
+	return best, found
+}
+
+// injectTagConditions ANDs a tag-matching clause for each predicate onto
+// an existing WHERE condition (which may be nil).
+func injectTagConditions(cond influxql.Expr, tags []TagPredicate) influxql.Expr {
+	for _, t := range tags {
+		var clause *influxql.BinaryExpr
+		if strings.HasSuffix(t.Pattern, "*") {
+			prefix := regexp.QuoteMeta(strings.TrimSuffix(t.Pattern, "*"))
+			clause = &influxql.BinaryExpr{
+				Op:  influxql.EQREGEX,
+				LHS: &influxql.VarRef{Val: t.Key},
+				RHS: &influxql.RegexLiteral{Val: regexp.MustCompile("^" + prefix)},
+			}
+		} else {
+			clause = &influxql.BinaryExpr{
+				Op:  influxql.EQ,
+				LHS: &influxql.VarRef{Val: t.Key},
+				RHS: &influxql.StringLiteral{Val: t.Pattern},
+			}
+		}
+
+		if cond == nil {
+			cond = clause
+		} else {
+			cond = &influxql.BinaryExpr{Op: influxql.AND, LHS: cond, RHS: clause}
+		}
+	}
+	return cond
+}
@@ -0,0 +1,70 @@
+package enterprise
+
+import "testing"
+
+func TestRewriteQueryInjectsTagFilter(t *testing.T) {
+	perms := Permissions{
+		"mydb.autogen.cpu[host=web*]": {"ReadData"},
+	}
+
+	rewritten, err := RewriteQuery(`SELECT * FROM cpu`, "mydb", "ReadData", perms)
+	if err != nil {
+		t.Fatalf("RewriteQuery returned unexpected error: %v", err)
+	}
+	if rewritten == `SELECT * FROM cpu` {
+		t.Errorf("expected a WHERE clause to be injected, got unchanged query %q", rewritten)
+	}
+}
+
+func TestRewriteQueryMatchesGrantWithUnspecifiedRetentionPolicy(t *testing.T) {
+	perms := Permissions{
+		"mydb.autogen.cpu": {"ReadData"},
+	}
+
+	// The query doesn't spell out a retention policy, the way real callers
+	// almost never do; it should still match the autogen-scoped grant
+	// rather than being denied for the mismatch.
+	if _, err := RewriteQuery(`SELECT * FROM cpu`, "mydb", "ReadData", perms); err != nil {
+		t.Errorf("RewriteQuery returned unexpected error: %v", err)
+	}
+}
+
+func TestRewriteQueryDeniesNonSelectStatement(t *testing.T) {
+	perms := Permissions{
+		"mydb.autogen.cpu": {"WriteData"},
+	}
+
+	if _, err := RewriteQuery(`DELETE FROM cpu`, "mydb", "WriteData", perms); err == nil {
+		t.Errorf("expected a non-SELECT statement to be denied rather than let through unchecked")
+	}
+}
+
+func TestRewriteQueryDeniesUnauthorizedMeasurement(t *testing.T) {
+	perms := Permissions{
+		"mydb.autogen.cpu": {"ReadData"},
+	}
+
+	if _, err := RewriteQuery(`SELECT * FROM secrets`, "mydb", "ReadData", perms); err == nil {
+		t.Errorf("expected an error querying a measurement with no grant, got nil")
+	}
+}
+
+func TestRewriteQueryDeniesSubquery(t *testing.T) {
+	perms := Permissions{
+		"mydb": {"ReadData"},
+	}
+
+	_, err := RewriteQuery(`SELECT * FROM (SELECT * FROM secrets)`, "mydb", "ReadData", perms)
+	if err == nil {
+		t.Errorf("expected subquery sources to be denied rather than silently let through")
+	}
+}
+
+func TestRewriteQueryRejectsFlux(t *testing.T) {
+	perms := Permissions{"mydb": {"ReadData"}}
+
+	_, err := RewriteQuery(`from(bucket: "mydb") |> range(start: -1h)`, "mydb", "ReadData", perms)
+	if err == nil {
+		t.Errorf("expected Flux queries to be rejected rather than parsed as InfluxQL")
+	}
+}
@@ -23,7 +23,13 @@ type Node struct {
 }
 
 // Permissions maps resources to a set of permissions.
-// Specifically, it maps a database to a set of permissions
+//
+// A key is most commonly just a database name, but it may also scope down
+// to a retention policy ("db.rp"), a measurement ("db.rp.measurement"), or
+// restrict to matching tag values ("db.rp.measurement[host=web*]"). See
+// ParseResource. Existing enterprise servers that only understand the
+// flat, database-only form continue to round-trip it unchanged, since it
+// is still just a map key.
 type Permissions map[string][]string
 
 // User represents an enterprise user.
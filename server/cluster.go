@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bouk/httprouter"
+)
+
+// clusterNodeStatus describes a single node's cached health for the
+// administrator-facing status view.
+type clusterNodeStatus struct {
+	Addr   string `json:"addr"`
+	Status string `json:"status"`
+}
+
+type clusterStatusResponse struct {
+	MetaNodes []clusterNodeStatus `json:"metaNodes"`
+	DataNodes []clusterNodeStatus `json:"dataNodes"`
+}
+
+// ClusterStatus reports the cached health of every node in the enterprise
+// cluster, as last observed by the Service's ClusterManager.
+func (s *Service) ClusterStatus(w http.ResponseWriter, r *http.Request) {
+	if s.ClusterManager == nil {
+		Error(w, http.StatusNotFound, "enterprise cluster management is not configured", s.Logger)
+		return
+	}
+
+	cluster := s.ClusterManager.Cluster()
+	res := clusterStatusResponse{}
+	for _, n := range cluster.MetaNodes {
+		res.MetaNodes = append(res.MetaNodes, clusterNodeStatus{Addr: n.Addr, Status: s.nodeStatus(n.Addr)})
+	}
+	for _, n := range cluster.DataNodes {
+		res.DataNodes = append(res.DataNodes, clusterNodeStatus{Addr: n.HTTPAddr, Status: s.nodeStatus(n.HTTPAddr)})
+	}
+	encodeJSON(w, http.StatusOK, res, s.Logger)
+}
+
+func (s *Service) nodeStatus(addr string) string {
+	h, ok := s.ClusterManager.Health(addr)
+	if !ok {
+		return "unknown"
+	}
+	return h.Status
+}
+
+type stepDownRequest struct {
+	MaxAttempts int `json:"maxAttempts"`
+	BackoffMS   int `json:"backoffMs"`
+}
+
+// StepDownMetaNode marks the meta node named by the "addr" route param for
+// maintenance, transferring its leadership away before it is taken out of
+// service so in-flight writes aren't interrupted.
+func (s *Service) StepDownMetaNode(w http.ResponseWriter, r *http.Request) {
+	if s.ClusterManager == nil {
+		Error(w, http.StatusNotFound, "enterprise cluster management is not configured", s.Logger)
+		return
+	}
+
+	ctx := r.Context()
+	addr := httprouter.GetParamFromContext(ctx, "addr")
+
+	req := stepDownRequest{MaxAttempts: 5, BackoffMS: 500}
+	if r.Body != nil {
+		// A missing or empty body is fine; we fall back to the defaults above.
+		_ = json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	backoff := time.Duration(req.BackoffMS) * time.Millisecond
+	if err := s.ClusterManager.StepDownMetaNode(ctx, addr, req.MaxAttempts, backoff); err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
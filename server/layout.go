@@ -194,6 +194,10 @@ func (s *Service) UpdateLayout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.Store.Layouts(ctx).Update(ctx, req); err != nil {
+		if conflict, ok := err.(*LayoutConflictError); ok {
+			Error(w, http.StatusConflict, conflict.Error(), s.Logger)
+			return
+		}
 		msg := fmt.Sprintf("Error updating layout ID %s: %v", id, err)
 		Error(w, http.StatusInternalServerError, msg, s.Logger)
 		return
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/bouk/httprouter"
+)
+
+// gitLayoutsStore returns the Service's LayoutsStore as a *GitLayoutsStore,
+// or nil if it isn't one. History and revert are only meaningful against
+// the git-backed store.
+func (s *Service) gitLayoutsStore(r *http.Request) *GitLayoutsStore {
+	store, _ := s.Store.Layouts(r.Context()).(*GitLayoutsStore)
+	return store
+}
+
+type layoutHistoryResponse struct {
+	Revisions []LayoutRevision `json:"revisions"`
+}
+
+// LayoutHistory returns every commit that has touched the layout named by
+// the "id" route param, most recent first.
+func (s *Service) LayoutHistory(w http.ResponseWriter, r *http.Request) {
+	store := s.gitLayoutsStore(r)
+	if store == nil {
+		Error(w, http.StatusNotFound, "layout history requires the git-backed layouts store", s.Logger)
+		return
+	}
+
+	ctx := r.Context()
+	id := httprouter.GetParamFromContext(ctx, "id")
+
+	revisions, err := store.History(ctx, id)
+	if err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+	encodeJSON(w, http.StatusOK, layoutHistoryResponse{Revisions: revisions}, s.Logger)
+}
+
+// RevertLayout rolls the layout named by the "id" route param back to the
+// content it had at the commit named by the "sha" route param.
+func (s *Service) RevertLayout(w http.ResponseWriter, r *http.Request) {
+	store := s.gitLayoutsStore(r)
+	if store == nil {
+		Error(w, http.StatusNotFound, "layout revert requires the git-backed layouts store", s.Logger)
+		return
+	}
+
+	ctx := r.Context()
+	id := httprouter.GetParamFromContext(ctx, "id")
+	sha := httprouter.GetParamFromContext(ctx, "sha")
+
+	layout, err := store.Revert(ctx, id, sha)
+	if err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+	encodeJSON(w, http.StatusOK, newLayoutResponse(layout), s.Logger)
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/influxdata/chronograf"
+)
+
+// layoutBundle is the JSON manifest a layout pack import is built from,
+// whether it arrives inline, fetched from a URL, or packaged inside a
+// tar.gz archive as manifest.json.
+type layoutBundle struct {
+	Layout    chronograf.Layout `json:"layout"`
+	Variables []LayoutVariable  `json:"variables,omitempty"`
+}
+
+// layoutImportRequest is the body of POST /chronograf/v1/layouts/import.
+// One of URL or Bundle must be set; when both are, the bundle fetched from
+// URL is used and Bundle is ignored. Signature, when the service has
+// any TrustedLayoutKeys configured, must be a valid ed25519 signature of
+// Bundle (or of the fetched URL contents) by one of those keys.
+type layoutImportRequest struct {
+	URL       string          `json:"url,omitempty"`
+	Bundle    json.RawMessage `json:"bundle,omitempty"`
+	Signature []byte          `json:"signature,omitempty"`
+	// SourceID is the chronograf source that Variables are resolved and
+	// validated against.
+	SourceID int `json:"sourceID"`
+	// Bindings resolves the bundle's Variables to concrete values, e.g.
+	// mapping a "host" tagValue variable to "web01".
+	Bindings map[string]string `json:"bindings,omitempty"`
+}
+
+// ImportLayout fetches or accepts a signed layout bundle (a JSON manifest
+// or a tar.gz archive containing one), validates its template variable
+// bindings against the target source, and stores the rendered layout —
+// the server side of the community layout pack ecosystem.
+func (s *Service) ImportLayout(w http.ResponseWriter, r *http.Request) {
+	var req layoutImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, s.Logger)
+		return
+	}
+
+	raw := req.Bundle
+	if req.URL != "" {
+		fetched, err := s.fetchLayoutBundle(r.Context(), req.URL)
+		if err != nil {
+			invalidData(w, err, s.Logger)
+			return
+		}
+		raw = fetched
+	}
+	if len(raw) == 0 {
+		invalidData(w, fmt.Errorf("one of url or bundle is required"), s.Logger)
+		return
+	}
+
+	if err := s.verifyLayoutBundleSignature(raw, req.Signature); err != nil {
+		Error(w, http.StatusForbidden, err.Error(), s.Logger)
+		return
+	}
+
+	bundle, err := decodeLayoutBundle(raw)
+	if err != nil {
+		invalidData(w, fmt.Errorf("malformed layout bundle: %v", err), s.Logger)
+		return
+	}
+
+	ctx := r.Context()
+	src, err := s.Store.Sources(ctx).Get(ctx, req.SourceID)
+	if err != nil {
+		invalidData(w, fmt.Errorf("importing against source %d: %v", req.SourceID, err), s.Logger)
+		return
+	}
+	ts, err := s.TimeSeriesClient.TimeSeries(src)
+	if err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+
+	for _, v := range bundle.Variables {
+		value, ok := req.Bindings[v.Name]
+		if !ok {
+			value = v.Default
+		}
+		if value == "" {
+			continue // no binding and no default: render will flag it as unresolved below
+		}
+		if err := validateVariableBinding(ctx, ts, v, value); err != nil {
+			invalidData(w, err, s.Logger)
+			return
+		}
+	}
+
+	layout := bundle.Layout
+	for ci, cell := range layout.Cells {
+		for qi, q := range cell.Queries {
+			missing := unresolvedVariables(q.Command, bundle.Variables, req.Bindings)
+			if len(missing) > 0 {
+				invalidData(w, fmt.Errorf("unresolved layout variables: %v", missing), s.Logger)
+				return
+			}
+			layout.Cells[ci].Queries[qi].Command = renderCommand(q.Command, bundle.Variables, req.Bindings)
+		}
+	}
+
+	defaultOrg, err := s.Store.Organizations(ctx).DefaultOrganization(ctx)
+	if err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+
+	if err := ValidLayoutRequest(layout, fmt.Sprintf("%d", defaultOrg.ID)); err != nil {
+		invalidData(w, err, s.Logger)
+		return
+	}
+
+	if layout, err = s.Store.Layouts(ctx).Add(ctx, layout); err != nil {
+		unknownErrorWithMessage(w, fmt.Errorf("error storing imported layout %v: %v", layout, err), s.Logger)
+		return
+	}
+
+	res := newLayoutResponse(layout)
+	location(w, res.Link.Href)
+	encodeJSON(w, http.StatusCreated, res, s.Logger)
+}
+
+// decodeLayoutBundle accepts either a JSON manifest or a gzip-compressed
+// tar archive containing one at manifest.json, detecting the format from
+// the gzip magic bytes rather than requiring the caller to say which it
+// sent.
+func decodeLayoutBundle(raw []byte) (layoutBundle, error) {
+	if len(raw) > 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		return decodeLayoutBundleTarGz(raw)
+	}
+
+	var bundle layoutBundle
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return layoutBundle{}, err
+	}
+	return bundle, nil
+}
+
+func decodeLayoutBundleTarGz(raw []byte) (layoutBundle, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return layoutBundle{}, fmt.Errorf("opening tar.gz bundle: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return layoutBundle{}, fmt.Errorf("tar.gz bundle has no manifest.json")
+		}
+		if err != nil {
+			return layoutBundle{}, fmt.Errorf("reading tar.gz bundle: %v", err)
+		}
+		if filepath.Base(hdr.Name) != "manifest.json" {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return layoutBundle{}, fmt.Errorf("reading manifest.json: %v", err)
+		}
+		var bundle layoutBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return layoutBundle{}, err
+		}
+		return bundle, nil
+	}
+}
+
+// layoutFetchTimeout bounds how long ImportLayout will wait on a
+// remote bundle URL before giving up.
+const layoutFetchTimeout = 10 * time.Second
+
+// layoutBundleHTTPClient is used for every layout bundle fetch. Its
+// DialContext re-validates the address actually being connected to
+// (rather than the host named in the URL, which a DNS lookup made
+// earlier could have resolved differently than the resolver the
+// transport ends up using) and its CheckRedirect re-validates each
+// redirect hop, so neither a DNS-rebinding attacker nor a 302 to
+// 169.254.169.254 can reach an address checkLayoutBundleURL would have
+// rejected up front.
+var layoutBundleHTTPClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if err := checkLayoutBundleURL(req.URL.String()); err != nil {
+			return err
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects fetching layout bundle")
+		}
+		return nil
+	},
+	Transport: &http.Transport{
+		DialContext: safeDialContext,
+	},
+}
+
+// safeDialContext dials addr like net.Dialer.DialContext, but refuses to
+// connect to any resolved IP that isn't a public address. Validating at
+// dial time (rather than trusting an earlier net.LookupIP of the URL's
+// host) closes the gap a DNS-rebinding attacker could otherwise use: the
+// address actually connected to is the one checked, not a possibly
+// different answer the same lookup returned moments earlier.
+func safeDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %q: %v", host, err)
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, ip := range ips {
+		if !isPublicAddr(ip.IP) {
+			lastErr = fmt.Errorf("address %q resolves to %s, a non-public address; refusing to connect", host, ip.IP)
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %q", host)
+	}
+	return nil, lastErr
+}
+
+// fetchLayoutBundle retrieves a bundle from url, refusing to fetch from
+// (or be redirected to) any host that resolves to a private, loopback,
+// or link-local address (including the common 169.254.169.254 cloud
+// metadata endpoint) so this server-side fetch can't be used to probe
+// internal infrastructure.
+func (s *Service) fetchLayoutBundle(ctx context.Context, rawURL string) ([]byte, error) {
+	if err := checkLayoutBundleURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, layoutFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching layout bundle from %s: %v", rawURL, err)
+	}
+
+	resp, err := layoutBundleHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching layout bundle from %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching layout bundle from %s: %s", rawURL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// checkLayoutBundleURL rejects bundle URLs that don't use http(s), or
+// whose host resolves to an address outside the public internet. This
+// is the up-front check on the URL as given; safeDialContext repeats the
+// equivalent check against the address actually dialed, since DNS can
+// answer differently between the two.
+func checkLayoutBundleURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid bundle url %q: %v", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("bundle url %q must be http or https", rawURL)
+	}
+
+	host := u.Hostname()
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("resolving bundle url host %q: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return fmt.Errorf("bundle url %q resolves to a non-public address; refusing to fetch it", rawURL)
+		}
+	}
+	return nil
+}
+
+// isPublicAddr reports whether addr is a publicly routable address, i.e.
+// not one of the private, loopback, or link-local ranges (including the
+// 169.254.169.254 cloud metadata endpoint) an SSRF would target.
+func isPublicAddr(addr net.IP) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast()
+}
+
+// verifyLayoutBundleSignature checks sig against every key in
+// s.TrustedLayoutKeys, succeeding if any one of them verifies. If no
+// trusted keys are configured, signature verification is skipped so
+// existing installs aren't broken by this feature.
+func (s *Service) verifyLayoutBundleSignature(bundle, sig []byte) error {
+	if len(s.TrustedLayoutKeys) == 0 {
+		return nil
+	}
+	if len(sig) == 0 {
+		return fmt.Errorf("layout bundle is unsigned but trusted keys are configured")
+	}
+	for _, key := range s.TrustedLayoutKeys {
+		if ed25519.Verify(key, bundle, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("layout bundle signature does not match any trusted key")
+}
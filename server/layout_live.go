@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bouk/httprouter"
+	"github.com/gorilla/websocket"
+	"github.com/influxdata/chronograf"
+)
+
+const (
+	// liveReadDeadline is how long a live connection may go without a
+	// control frame from the client before it's considered dead.
+	liveReadDeadline = 60 * time.Second
+	// livePingInterval is how often the server pings the client; it must
+	// be comfortably shorter than liveReadDeadline so a healthy but
+	// passive browser tab (which never sends anything on its own) still
+	// gets its deadline reset via the resulting pong.
+	livePingInterval = 30 * time.Second
+	// liveWriteDeadline bounds a single frame write.
+	liveWriteDeadline = 10 * time.Second
+	// liveClientBuffer is how many pending frames a slow client is
+	// allowed to fall behind by before the oldest are dropped.
+	liveClientBuffer = 32
+	// liveQueryInterval is how often each cell's query is re-run.
+	liveQueryInterval = time.Second
+)
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// Dashboards are served from the same origin as the API, so the
+	// default same-origin check protects this the way it protects the
+	// rest of the API.
+}
+
+// liveFrame is one newline-delimited JSON message sent to the browser.
+type liveFrame struct {
+	Type    string          `json:"type"`
+	CellID  string          `json:"cellId,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Dropped int             `json:"dropped,omitempty"`
+}
+
+// LayoutLive upgrades to a websocket and streams incremental query results
+// for every cell in the layout named by the "id" route param, one upstream
+// subscription per cell query. Variable bindings and the "sourceID" to query
+// against are taken from the query string, the same as a one-shot render
+// would use.
+func (s *Service) LayoutLive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := httprouter.GetParamFromContext(ctx, "id")
+
+	layout, err := s.Store.Layouts(ctx).Get(ctx, id)
+	if err != nil {
+		Error(w, http.StatusNotFound, fmt.Sprintf("ID %s not found", id), s.Logger)
+		return
+	}
+
+	sourceID, err := strconv.Atoi(r.URL.Query().Get("sourceID"))
+	if err != nil {
+		invalidData(w, fmt.Errorf("sourceID query parameter is required: %v", err), s.Logger)
+		return
+	}
+	src, err := s.Store.Sources(ctx).Get(ctx, sourceID)
+	if err != nil {
+		Error(w, http.StatusNotFound, fmt.Sprintf("source %d not found", sourceID), s.Logger)
+		return
+	}
+	ts, err := s.TimeSeriesClient.TimeSeries(src)
+	if err != nil {
+		unknownErrorWithMessage(w, err, s.Logger)
+		return
+	}
+
+	bindings := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			bindings[k] = v[0]
+		}
+	}
+
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.Logger.Error("layout live: upgrade failed: ", err)
+		return
+	}
+	defer conn.Close()
+
+	session := newLiveSession(conn)
+	defer session.close()
+
+	go session.readControlFrames()
+
+	for ci, cell := range layout.Cells {
+		cellID := fmt.Sprintf("%d", ci)
+		for _, q := range cell.Queries {
+			command := renderCommand(q.Command, nil, bindings)
+			go session.streamCell(ts, cellID, command)
+		}
+	}
+
+	<-session.done
+}
+
+// liveSession multiplexes every cell's upstream stream onto a single
+// websocket connection, applying a shared deadline timer and a
+// drop-oldest backpressure policy so one slow cell can't stall the rest.
+//
+// mu guards queue and dropped; writeLoop is the queue's sole consumer, so
+// frames are never reordered. writeMu serializes writes to the connection
+// (writeLoop's frames and pingLoop's pings) and is never held together
+// with mu, so a write stalled on a slow client doesn't block enqueue.
+type liveSession struct {
+	conn *websocket.Conn
+
+	mu      sync.Mutex
+	queue   []liveFrame
+	dropped int
+
+	writeMu sync.Mutex
+
+	notify chan struct{}
+	done   chan struct{}
+	closed chan struct{}
+}
+
+func newLiveSession(conn *websocket.Conn) *liveSession {
+	s := &liveSession{
+		conn:   conn,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+	conn.SetReadDeadline(time.Now().Add(liveReadDeadline))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(liveReadDeadline))
+		return nil
+	})
+	go s.writeLoop()
+	go s.pingLoop()
+	return s
+}
+
+func (s *liveSession) close() {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+// readControlFrames blocks reading control frames (pings/pongs/close) so
+// incoming pongs keep resetting the read deadline, and a client disconnect
+// is noticed promptly; it ends the session once the connection drops.
+func (s *liveSession) readControlFrames() {
+	defer close(s.done)
+	for {
+		if _, _, err := s.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// pingLoop periodically pings the client so a healthy but passive reader
+// (a browser tab that never itself sends anything) keeps resetting its
+// peer's read deadline via the resulting pong, instead of being torn down
+// as though it had gone away.
+func (s *liveSession) pingLoop() {
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			s.conn.SetWriteDeadline(time.Now().Add(liveWriteDeadline))
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteDeadline))
+			s.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeLoop drains queued frames to the websocket, applying a fresh write
+// deadline to each one. Any frames dropped since the last write are
+// reported as a single aggregated "lag" frame immediately before the next
+// delivered frame.
+func (s *liveSession) writeLoop() {
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-s.notify:
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			frame := s.queue[0]
+			s.queue = s.queue[1:]
+			dropped := s.dropped
+			s.dropped = 0
+			s.mu.Unlock()
+
+			if dropped > 0 {
+				if !s.writeFrame(liveFrame{Type: "lag", Dropped: dropped}) {
+					return
+				}
+			}
+			if !s.writeFrame(frame) {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame writes a single frame with a fresh write deadline. writeMu
+// also guards the ping loop's control-frame writes, since gorilla/websocket
+// connections only support one writer at a time.
+func (s *liveSession) writeFrame(frame liveFrame) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(liveWriteDeadline))
+	return s.conn.WriteJSON(frame) == nil
+}
+
+// enqueue delivers frame to the client, dropping the oldest queued frame
+// instead of blocking the upstream subscription when the client has
+// fallen too far behind. Every frame dropped this way is counted and
+// reported to the client as a single aggregated "lag" notice ahead of the
+// next frame writeLoop actually sends.
+func (s *liveSession) enqueue(frame liveFrame) {
+	s.mu.Lock()
+	if len(s.queue) >= liveClientBuffer {
+		s.queue = s.queue[1:]
+		s.dropped++
+	}
+	s.queue = append(s.queue, frame)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// streamCell opens an upstream subscription for one cell's query and
+// pushes each incremental result to the client until the session ends.
+func (s *liveSession) streamCell(ts chronograf.TimeSeries, cellID, command string) {
+	ticker := time.NewTicker(liveQueryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			result, err := queryOnce(ts, command)
+			if err != nil {
+				s.enqueue(liveFrame{Type: "error", CellID: cellID, Result: errorResult(err)})
+				continue
+			}
+			s.enqueue(liveFrame{Type: "result", CellID: cellID, Result: result})
+		}
+	}
+}
+
+// queryOnce runs command against ts and marshals its response for delivery
+// as a single live frame.
+func queryOnce(ts chronograf.TimeSeries, command string) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), liveQueryInterval)
+	defer cancel()
+
+	response, err := ts.Query(ctx, chronograf.Query{Command: command})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(response)
+}
+
+func errorResult(err error) json.RawMessage {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+	return data
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/influxdata/chronograf"
+)
+
+// VariableType describes the kind of value a LayoutVariable resolves to,
+// which in turn determines how its value is validated against a source
+// before a templated layout is rendered.
+type VariableType string
+
+const (
+	VariableMeasurement VariableType = "measurement"
+	VariableTagKey      VariableType = "tagKey"
+	VariableTagValue    VariableType = "tagValue"
+	VariableDuration    VariableType = "duration"
+	VariableString      VariableType = "string"
+)
+
+// LayoutVariable describes one `${var}` placeholder that may appear in a
+// layout cell's query Command, along with the default to use when no
+// binding is supplied at render time.
+type LayoutVariable struct {
+	Name    string       `json:"name"`
+	Type    VariableType `json:"type"`
+	Default string       `json:"default,omitempty"`
+}
+
+// variablePattern matches a `${name}` placeholder within a query Command.
+var variablePattern = regexp.MustCompile(`\$\{([a-zA-Z0-9_]+)\}`)
+
+// renderCommand substitutes every `${var}` placeholder in command with the
+// value bound to it, falling back to each variable's Default when no
+// binding was supplied. Placeholders with neither a binding nor a default
+// are left untouched so the caller can surface a clear validation error.
+func renderCommand(command string, variables []LayoutVariable, bindings map[string]string) string {
+	defaults := make(map[string]string, len(variables))
+	for _, v := range variables {
+		if v.Default != "" {
+			defaults[v.Name] = v.Default
+		}
+	}
+
+	return variablePattern.ReplaceAllStringFunc(command, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if v, ok := bindings[name]; ok {
+			return v
+		}
+		if v, ok := defaults[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+// unresolvedVariables returns the names of every `${var}` placeholder in
+// command that renderCommand would leave untouched given bindings and
+// variables.
+func unresolvedVariables(command string, variables []LayoutVariable, bindings map[string]string) []string {
+	defaults := make(map[string]string, len(variables))
+	for _, v := range variables {
+		if v.Default != "" {
+			defaults[v.Name] = v.Default
+		}
+	}
+
+	var missing []string
+	for _, match := range variablePattern.FindAllStringSubmatch(command, -1) {
+		name := match[1]
+		if _, ok := bindings[name]; ok {
+			continue
+		}
+		if _, ok := defaults[name]; ok {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// validateVariableBinding checks value against v's declared Type,
+// querying ts to confirm existence for the types (measurement, tagKey,
+// tagValue) that name something in the target source rather than being
+// free-form text.
+func validateVariableBinding(ctx context.Context, ts chronograf.TimeSeries, v LayoutVariable, value string) error {
+	switch v.Type {
+	case VariableString:
+		return nil
+	case VariableDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("variable %q: %q is not a valid duration: %v", v.Name, value, err)
+		}
+		return nil
+	case VariableMeasurement:
+		return checkSourceHasRow(ctx, ts, fmt.Sprintf("SHOW MEASUREMENTS WITH MEASUREMENT = %s", quoteIdent(value)), v, value)
+	case VariableTagKey:
+		return checkSourceHasRow(ctx, ts, fmt.Sprintf("SHOW TAG KEYS WHERE tagKey = %s", quoteString(value)), v, value)
+	case VariableTagValue:
+		return checkSourceHasRow(ctx, ts, fmt.Sprintf("SHOW TAG VALUES WITH KEY = %s", quoteIdent(value)), v, value)
+	default:
+		return fmt.Errorf("variable %q: unknown type %q", v.Name, v.Type)
+	}
+}
+
+// checkSourceHasRow runs query against ts and fails validation if it
+// returns no rows, meaning value doesn't exist in the target source.
+func checkSourceHasRow(ctx context.Context, ts chronograf.TimeSeries, query string, v LayoutVariable, value string) error {
+	response, err := ts.Query(ctx, chronograf.Query{Command: query})
+	if err != nil {
+		return fmt.Errorf("variable %q: validating %q against the source: %v", v.Name, value, err)
+	}
+	if !responseHasRows(response) {
+		return fmt.Errorf("variable %q: %q was not found in the target source", v.Name, value)
+	}
+	return nil
+}
+
+func quoteIdent(s string) string  { return `"` + s + `"` }
+func quoteString(s string) string { return `'` + s + `'` }
+
+// responseHasRows reports whether an InfluxQL query Response contains at
+// least one result row, by marshaling it to the standard
+// {"results":[{"series":[...]}]} shape rather than assuming a particular
+// Go representation of chronograf.Response.
+func responseHasRows(response chronograf.Response) bool {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return false
+	}
+
+	var parsed struct {
+		Results []struct {
+			Series []struct {
+				Values [][]interface{} `json:"values"`
+			} `json:"series"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return false
+	}
+
+	for _, result := range parsed.Results {
+		for _, series := range result.Series {
+			if len(series.Values) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/influxdata/chronograf"
+	"github.com/influxdata/chronograf/enterprise"
+)
+
+// LayoutConflictError is returned by GitLayoutsStore.Update when the
+// layout has been changed since the caller last read it. SHA is the
+// commit the store's copy is actually at, so the caller can fetch that
+// version and rebase their edit onto it.
+type LayoutConflictError struct {
+	ID  string
+	SHA string
+}
+
+func (e *LayoutConflictError) Error() string {
+	return fmt.Sprintf("layout %s was updated concurrently; current version is %s", e.ID, e.SHA)
+}
+
+// GitLayoutsStore persists layouts as one JSON file per layout in a git
+// repository, so every NewLayout/UpdateLayout/RemoveLayout produces a
+// commit attributed to the acting user and dashboards gain the review and
+// rollback story that comes for free with git history.
+type GitLayoutsStore struct {
+	repo *git.Repository
+	dir  string // working copy path, relative to the repo root, holding one file per layout
+
+	// mu serializes every operation that stages or commits against the
+	// repository's single worktree, since go-git's Worktree has no
+	// built-in protection against concurrent Add/Commit/Remove calls.
+	mu sync.Mutex
+}
+
+// NewGitLayoutsStore opens the git repository at path, initializing a new
+// non-bare repository with a working copy there if one doesn't already
+// exist.
+func NewGitLayoutsStore(path string) (*GitLayoutsStore, error) {
+	repo, err := git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(path, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening layouts git store at %s: %v", path, err)
+	}
+	return &GitLayoutsStore{repo: repo, dir: "layouts"}, nil
+}
+
+func (s *GitLayoutsStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *GitLayoutsStore) worktree() (*git.Worktree, error) {
+	return s.repo.Worktree()
+}
+
+// commitLayout acquires s.mu and delegates to commitLayoutLocked. Callers
+// that need to hold the lock across a preceding check (Update's
+// optimistic-concurrency check, for instance) should call
+// commitLayoutLocked directly instead.
+func (s *GitLayoutsStore) commitLayout(ctx context.Context, layout chronograf.Layout, message string) (plumbing.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commitLayoutLocked(ctx, layout, message)
+}
+
+// commitLayoutLocked writes layout to its file in the working copy and
+// commits it, attributing the commit to the user attached to ctx (falling
+// back to a generic service identity if none is present). Callers must
+// hold s.mu.
+func (s *GitLayoutsStore) commitLayoutLocked(ctx context.Context, layout chronograf.Layout, message string) (plumbing.Hash, error) {
+	wt, err := s.worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	full := filepath.Join(wt.Filesystem.Root(), s.path(layout.ID))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	data, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := ioutil.WriteFile(full, data, 0644); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := wt.Add(s.path(layout.ID)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	name, email := s.author(ctx)
+	return wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: name, Email: email, When: time.Now()},
+	})
+}
+
+func (s *GitLayoutsStore) author(ctx context.Context) (name, email string) {
+	if user, ok := enterprise.UserFromContext(ctx); ok {
+		return user.Name, user.Name + "@chronograf.local"
+	}
+	return "chronograf", "chronograf@chronograf.local"
+}
+
+// Add stores layout and commits it. If layout.ID is empty, the caller is
+// expected to have already assigned one, matching the other LayoutsStore
+// implementations.
+func (s *GitLayoutsStore) Add(ctx context.Context, layout chronograf.Layout) (chronograf.Layout, error) {
+	hash, err := s.commitLayout(ctx, layout, fmt.Sprintf("add layout %s", layout.ID))
+	if err != nil {
+		return chronograf.Layout{}, err
+	}
+	layout.Revision = hash.String()
+	return layout, nil
+}
+
+// Get reads the current version of the layout with id from HEAD and
+// stamps it with the commit SHA it was read at, so a caller that later
+// calls Update with that Revision gets a 409 (rather than silently
+// clobbering someone else's change) if another write has landed in the
+// meantime.
+func (s *GitLayoutsStore) Get(ctx context.Context, id string) (chronograf.Layout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wt, err := s.worktree()
+	if err != nil {
+		return chronograf.Layout{}, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(wt.Filesystem.Root(), s.path(id)))
+	if err != nil {
+		return chronograf.Layout{}, fmt.Errorf("layout %s not found: %v", id, err)
+	}
+
+	var layout chronograf.Layout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return chronograf.Layout{}, err
+	}
+
+	sha, err := s.headSHA(id)
+	if err != nil {
+		return chronograf.Layout{}, err
+	}
+	layout.Revision = sha
+	return layout, nil
+}
+
+// All returns every layout currently checked out in the working copy.
+func (s *GitLayoutsStore) All(ctx context.Context) ([]chronograf.Layout, error) {
+	wt, err := s.worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(wt.Filesystem.Root(), s.dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	layouts := make([]chronograf.Layout, 0, len(entries))
+	for _, entry := range entries {
+		id := entry.Name()[:len(entry.Name())-len(filepath.Ext(entry.Name()))]
+		layout, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		layouts = append(layouts, layout)
+	}
+	return layouts, nil
+}
+
+// Update replaces the stored layout with req and commits the change. If
+// req.Revision is set and doesn't match the layout's current commit SHA,
+// Update returns a *LayoutConflictError rather than overwriting a change
+// it hasn't seen.
+func (s *GitLayoutsStore) Update(ctx context.Context, req chronograf.Layout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Revision != "" {
+		head, err := s.headSHA(req.ID)
+		if err != nil {
+			return err
+		}
+		if head != req.Revision {
+			return &LayoutConflictError{ID: req.ID, SHA: head}
+		}
+	}
+
+	_, err := s.commitLayoutLocked(ctx, req, fmt.Sprintf("update layout %s", req.ID))
+	return err
+}
+
+// Delete removes the layout's file and commits the removal.
+func (s *GitLayoutsStore) Delete(ctx context.Context, layout chronograf.Layout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wt, err := s.worktree()
+	if err != nil {
+		return err
+	}
+
+	if _, err := wt.Remove(s.path(layout.ID)); err != nil {
+		return err
+	}
+
+	name, email := s.author(ctx)
+	_, err = wt.Commit(fmt.Sprintf("remove layout %s", layout.ID), &git.CommitOptions{
+		Author: &object.Signature{Name: name, Email: email, When: time.Now()},
+	})
+	return err
+}
+
+// LayoutRevision is one commit in a layout's history, as returned by
+// History.
+type LayoutRevision struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// History returns every commit that touched the layout with id, most
+// recent first.
+func (s *GitLayoutsStore) History(ctx context.Context, id string) ([]LayoutRevision, error) {
+	head, err := s.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := s.repo.Log(&git.LogOptions{From: head.Hash(), FileName: strPtr(s.path(id))})
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []LayoutRevision
+	err = commits.ForEach(func(c *object.Commit) error {
+		revisions = append(revisions, LayoutRevision{
+			SHA:     c.Hash.String(),
+			Author:  c.Author.Name,
+			Time:    c.Author.When,
+			Message: c.Message,
+		})
+		return nil
+	})
+	return revisions, err
+}
+
+// Revert checks out the layout with id as it existed at sha and commits
+// that content as the new current version, leaving a clear audit trail of
+// the rollback rather than rewriting history.
+func (s *GitLayoutsStore) Revert(ctx context.Context, id, sha string) (chronograf.Layout, error) {
+	commit, err := s.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return chronograf.Layout{}, fmt.Errorf("revision %s not found: %v", sha, err)
+	}
+
+	file, err := commit.File(s.path(id))
+	if err != nil {
+		return chronograf.Layout{}, fmt.Errorf("layout %s was not present at %s: %v", id, sha, err)
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return chronograf.Layout{}, err
+	}
+
+	var layout chronograf.Layout
+	if err := json.Unmarshal([]byte(contents), &layout); err != nil {
+		return chronograf.Layout{}, err
+	}
+
+	newHash, err := s.commitLayout(ctx, layout, fmt.Sprintf("revert layout %s to %s", id, sha))
+	if err != nil {
+		return chronograf.Layout{}, err
+	}
+	layout.Revision = newHash.String()
+	return layout, nil
+}
+
+func (s *GitLayoutsStore) headSHA(id string) (string, error) {
+	head, err := s.repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := s.repo.Log(&git.LogOptions{From: head.Hash(), FileName: strPtr(s.path(id))})
+	if err != nil {
+		return "", err
+	}
+
+	c, err := commits.Next()
+	if err == io.EOF {
+		return "", nil // no history yet for this layout
+	} else if err != nil {
+		return "", err
+	}
+	return c.Hash.String(), nil
+}
+
+func strPtr(s string) *string { return &s }
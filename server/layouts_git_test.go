@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+)
+
+func TestGitLayoutsStoreUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewGitLayoutsStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewGitLayoutsStore: %v", err)
+	}
+
+	original := chronograf.Layout{ID: "layout1", Application: "app", Measurement: "cpu"}
+	added, err := store.Add(ctx, original)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if added.Revision == "" {
+		t.Fatalf("Add did not stamp a Revision on the stored layout")
+	}
+
+	// A concurrent writer lands an update first.
+	concurrent := added
+	concurrent.Measurement = "mem"
+	if err := store.Update(ctx, concurrent); err != nil {
+		t.Fatalf("first Update: %v", err)
+	}
+
+	// The original caller, still holding the stale Revision it read
+	// before the concurrent write, tries to update too.
+	stale := added
+	stale.Measurement = "disk"
+	err = store.Update(ctx, stale)
+	if err == nil {
+		t.Fatalf("expected a conflict updating with a stale Revision, got nil error")
+	}
+	conflict, ok := err.(*LayoutConflictError)
+	if !ok {
+		t.Fatalf("expected a *LayoutConflictError, got %T: %v", err, err)
+	}
+	if conflict.SHA == "" || conflict.SHA == added.Revision {
+		t.Errorf("conflict SHA %q should be the new current revision, not the stale one", conflict.SHA)
+	}
+
+	// Get should return the winning write with its own fresh Revision.
+	current, err := store.Get(ctx, "layout1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if current.Measurement != "mem" {
+		t.Errorf("Get returned Measurement %q, want %q", current.Measurement, "mem")
+	}
+	if current.Revision != conflict.SHA {
+		t.Errorf("Get returned Revision %q, want the conflicting SHA %q", current.Revision, conflict.SHA)
+	}
+}
+
+func TestGitLayoutsStoreHistory(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewGitLayoutsStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewGitLayoutsStore: %v", err)
+	}
+
+	layout := chronograf.Layout{ID: "layout1", Application: "app", Measurement: "cpu"}
+	if _, err := store.Add(ctx, layout); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	layout.Measurement = "mem"
+	if err := store.Update(ctx, layout); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	revisions, err := store.History(ctx, "layout1")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("History returned %d revisions, want 2", len(revisions))
+	}
+
+	reverted, err := store.Revert(ctx, "layout1", revisions[1].SHA)
+	if err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	if reverted.Measurement != "cpu" {
+		t.Errorf("Revert restored Measurement %q, want %q", reverted.Measurement, "cpu")
+	}
+	if reverted.Revision == "" || reverted.Revision == revisions[1].SHA {
+		t.Errorf("Revert should stamp the new commit's Revision, got %q", reverted.Revision)
+	}
+}